@@ -0,0 +1,175 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type batchItem struct {
+	kind string // "query" or "exec"
+}
+
+type batchWrap struct {
+	mu    sync.Mutex
+	batch *pgx.Batch
+	items []batchItem
+}
+
+var batchTable sync.Map // id(uint64) -> *batchWrap
+
+func sqlIsQuery(sql string) bool {
+	s := strings.TrimSpace(sql)
+	i := strings.IndexAny(s, " \t\n(")
+	if i >= 0 {
+		s = s[:i]
+	}
+	switch strings.ToLower(s) {
+	case "select", "with", "values", "show", "table":
+		return true
+	default:
+		return false
+	}
+}
+
+//export BatchNew
+func BatchNew() *C.char {
+
+	id := atomic.AddUint64(&hCounter, 1)
+	batchTable.Store(id, &batchWrap{batch: &pgx.Batch{}})
+
+	return C.CString(fmt.Sprintf(`{"handle":%d}`, id))
+
+}
+
+//export BatchQueue
+func BatchQueue(batchHandle C.ulonglong, sql *C.char, params *C.char, kind *C.char) *C.char {
+
+	id := uint64(batchHandle)
+	v, ok := batchTable.Load(id)
+	if !ok {
+		return C.CString(`{"error":"invalid batch handle"}`)
+	}
+	w := v.(*batchWrap)
+
+	q := C.GoString(sql)
+	p := C.GoString(params)
+	k := strings.ToLower(strings.TrimSpace(C.GoString(kind)))
+
+	var args []any
+	if len(p) > 0 {
+		var err error
+		args, err = jsonToArgs([]byte(p))
+		if err != nil {
+			return C.CString(string(jsonErr(fmt.Errorf("bad params json: %w", err))))
+		}
+	}
+
+	switch k {
+	case "query", "exec":
+	case "":
+		if sqlIsQuery(q) {
+			k = "query"
+		} else {
+			k = "exec"
+		}
+	default:
+		return C.CString(string(jsonErr(fmt.Errorf("unknown kind %q", k))))
+	}
+
+	w.mu.Lock()
+	w.batch.Queue(q, args...)
+	w.items = append(w.items, batchItem{kind: k})
+	w.mu.Unlock()
+
+	return C.CString(`{"ok":true}`)
+
+}
+
+//export BatchSend
+func BatchSend(handle C.ulonglong, batchHandle C.ulonglong, format *C.char) *C.char {
+
+	v, ok := connTable.Load(uint64(handle))
+	if !ok {
+		return C.CString(`{"error":"invalid handle"}`)
+	}
+	pw, ok := v.(*poolWrap)
+	if !ok {
+		return C.CString(`{"error":"handle is not a pool"}`)
+	}
+
+	bv, ok := batchTable.Load(uint64(batchHandle))
+	if !ok {
+		return C.CString(`{"error":"invalid batch handle"}`)
+	}
+	w := bv.(*batchWrap)
+
+	f := strings.ToLower(C.GoString(format))
+	ctx := context.Background()
+
+	br := pw.conn.SendBatch(ctx, w.batch)
+	defer br.Close()
+
+	results := make([]json.RawMessage, 0, len(w.items))
+
+	for _, item := range w.items {
+		switch item.kind {
+		case "query":
+			rows, err := br.Query()
+			if err != nil {
+				results = append(results, jsonErr(err))
+				continue
+			}
+			var data []byte
+			if f == "json" {
+				data, err = rowsToJSON(rows)
+			} else {
+				data, err = rowsToList(rows)
+			}
+			rows.Close()
+			if err != nil {
+				results = append(results, jsonErr(err))
+				continue
+			}
+			results = append(results, data)
+		default:
+			ct, err := br.Exec()
+			if err != nil {
+				results = append(results, jsonErr(err))
+				continue
+			}
+			results = append(results, json.RawMessage(fmt.Sprintf(`{"rows_affected":%d}`, ct.RowsAffected())))
+		}
+	}
+
+	out, err := json.Marshal(results)
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	return C.CString(string(out))
+
+}
+
+//export BatchClose
+func BatchClose(batchHandle C.ulonglong) *C.char {
+
+	id := uint64(batchHandle)
+	if _, ok := batchTable.Load(id); !ok {
+		return C.CString(`{"ok":false, "error":"invalid batch handle"}`)
+	}
+
+	batchTable.Delete(id)
+
+	return C.CString(`{"ok":true}`)
+
+}
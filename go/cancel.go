@@ -0,0 +1,112 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type cancelWrap struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+var cancelTable sync.Map // id(uint64) -> *cancelWrap
+
+//export CancelNew
+func CancelNew() *C.char {
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	id := atomic.AddUint64(&hCounter, 1)
+	cancelTable.Store(id, &cancelWrap{ctx: ctx, cancel: cancel})
+
+	return C.CString(fmt.Sprintf(`{"cancel_id":%d}`, id))
+
+}
+
+// Cancel cancels the context registered under cancelID. If a query is
+// in flight on it, pgx turns the context cancellation into a PostgreSQL
+// cancel request over the underlying pgconn rather than just abandoning
+// the Go goroutine, so the server-side statement actually stops running.
+//
+//export Cancel
+func Cancel(cancelID C.ulonglong) *C.char {
+
+	id := uint64(cancelID)
+
+	v, ok := cancelTable.Load(id)
+	if !ok {
+		return C.CString(`{"ok":false, "error":"invalid cancel id"}`)
+	}
+
+	v.(*cancelWrap).cancel()
+	cancelTable.Delete(id)
+
+	return C.CString(`{"ok":true}`)
+
+}
+
+// callCtx builds the context for a single Query/Execute call: it starts
+// from the cancellable context registered under cancelID (or
+// context.Background() if none was given), then layers a timeout on top
+// when timeoutMs is non-zero. The returned cancel func must always be
+// called to release the timer.
+func callCtx(cancelID uint64, timeoutMs uint64) (context.Context, context.CancelFunc) {
+
+	ctx := context.Background()
+
+	if cancelID != 0 {
+		if v, ok := cancelTable.Load(cancelID); ok {
+			ctx = v.(*cancelWrap).ctx
+		}
+	}
+
+	if timeoutMs == 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+
+}
+
+//export ExecuteEx
+func ExecuteEx(handle C.ulonglong, query *C.char, params *C.char, timeoutMs C.ulonglong, cancelID C.ulonglong) *C.char {
+
+	id := uint64(handle)
+	q := C.GoString(query)
+	p := C.GoString(params)
+
+	ctx, cancel := callCtx(uint64(cancelID), uint64(timeoutMs))
+	defer cancel()
+
+	if len(p) == 0 {
+		return execJSON(ctx, id, q)
+	}
+	return execParamsJSON(ctx, id, q, p)
+
+}
+
+//export QueryEx
+func QueryEx(handle C.ulonglong, query *C.char, params *C.char, format *C.char, timeoutMs C.ulonglong, cancelID C.ulonglong) *C.char {
+
+	id := uint64(handle)
+	q := C.GoString(query)
+	p := C.GoString(params)
+	f := C.GoString(format)
+
+	ctx, cancel := callCtx(uint64(cancelID), uint64(timeoutMs))
+	defer cancel()
+
+	if len(p) == 0 {
+		return queryJSON(ctx, id, q, f)
+	}
+	return queryParamsJSON(ctx, id, q, p, f)
+
+}
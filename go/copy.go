@@ -0,0 +1,117 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// countingWriter tallies bytes written so CopyToJSON can report the
+// real byte count rather than CopyTo's row count.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+//export CopyFromJSON
+func CopyFromJSON(handle C.ulonglong, table *C.char, columns *C.char, rows *C.char) *C.char {
+
+	v, ok := connTable.Load(uint64(handle))
+	if !ok {
+		return C.CString(`{"error":"invalid handle"}`)
+	}
+	pw, ok := v.(*poolWrap)
+	if !ok {
+		return C.CString(`{"error":"handle is not a pool"}`)
+	}
+
+	var cols []string
+	if err := json.Unmarshal([]byte(C.GoString(columns)), &cols); err != nil {
+		return C.CString(string(jsonErr(fmt.Errorf("bad columns json: %w", err))))
+	}
+
+	var data [][]any
+	if err := json.Unmarshal([]byte(C.GoString(rows)), &data); err != nil {
+		return C.CString(string(jsonErr(fmt.Errorf("bad rows json: %w", err))))
+	}
+
+	ctx := context.Background()
+	n, err := pw.conn.CopyFrom(ctx, pgx.Identifier{C.GoString(table)}, cols, pgx.CopyFromRows(data))
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	return C.CString(fmt.Sprintf(`{"rows_copied":%d}`, n))
+
+}
+
+//export CopyToJSON
+func CopyToJSON(handle C.ulonglong, sql *C.char, bufferPath *C.char) *C.char {
+
+	v, ok := connTable.Load(uint64(handle))
+	if !ok {
+		return C.CString(`{"error":"invalid handle"}`)
+	}
+	pw, ok := v.(*poolWrap)
+	if !ok {
+		return C.CString(`{"error":"handle is not a pool"}`)
+	}
+
+	ctx := context.Background()
+
+	acquired, err := pw.conn.Acquire(ctx)
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+	defer acquired.Release()
+
+	path := C.GoString(bufferPath)
+
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return C.CString(string(jsonErr(err)))
+		}
+		defer f.Close()
+
+		cw := &countingWriter{w: f}
+		if _, err := acquired.Conn().PgConn().CopyTo(ctx, cw, C.GoString(sql)); err != nil {
+			return C.CString(string(jsonErr(err)))
+		}
+
+		return C.CString(fmt.Sprintf(`{"bytes_written":%d}`, cw.n))
+	}
+
+	var buf bytes.Buffer
+	_, err = acquired.Conn().PgConn().CopyTo(ctx, &buf, C.GoString(sql))
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	resp, err := json.Marshal(map[string]any{
+		"bytes_written": buf.Len(),
+		"data":          base64.StdEncoding.EncodeToString(buf.Bytes()),
+	})
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	return C.CString(string(resp))
+
+}
@@ -0,0 +1,145 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const notificationBuffer = 64
+
+type subWrap struct {
+	conn   *pgxpool.Conn
+	notify chan *notification
+	cancel context.CancelFunc
+	done   chan struct{} // closed once the notification-reading goroutine has returned
+}
+
+type notification struct {
+	Channel string `json:"channel"`
+	Payload string `json:"payload"`
+	PID     uint32 `json:"pid"`
+}
+
+var subTable sync.Map // id(uint64) -> *subWrap
+
+//export Listen
+func Listen(handle C.ulonglong, channel *C.char) *C.char {
+
+	v, ok := connTable.Load(uint64(handle))
+	if !ok {
+		return C.CString(`{"error":"invalid handle"}`)
+	}
+	pw, ok := v.(*poolWrap)
+	if !ok {
+		return C.CString(`{"error":"handle is not a pool"}`)
+	}
+
+	ch := C.GoString(channel)
+
+	acquireCtx := context.Background()
+	conn, err := pw.conn.Acquire(acquireCtx)
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	if _, err := conn.Exec(acquireCtx, "LISTEN "+pgx.Identifier{ch}.Sanitize()); err != nil {
+		conn.Release()
+		return C.CString(string(jsonErr(err)))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &subWrap{
+		conn:   conn,
+		notify: make(chan *notification, notificationBuffer),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	id := atomic.AddUint64(&hCounter, 1)
+	subTable.Store(id, sub)
+
+	go func() {
+		defer close(sub.done)
+		for {
+			n, err := conn.Conn().WaitForNotification(ctx)
+			if err != nil {
+				return
+			}
+			select {
+			case sub.notify <- &notification{Channel: n.Channel, Payload: n.Payload, PID: n.PID}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return C.CString(fmt.Sprintf(`{"sub_id":%d}`, id))
+
+}
+
+//export NextNotification
+func NextNotification(subID C.ulonglong, timeoutMs C.ulonglong) *C.char {
+
+	v, ok := subTable.Load(uint64(subID))
+	if !ok {
+		return C.CString(`{"error":"invalid sub id"}`)
+	}
+	sub := v.(*subWrap)
+
+	var timer <-chan time.Time
+	if timeoutMs > 0 {
+		t := time.NewTimer(time.Duration(timeoutMs) * time.Millisecond)
+		defer t.Stop()
+		timer = t.C
+	}
+
+	select {
+	case n := <-sub.notify:
+		data, err := json.Marshal(n)
+		if err != nil {
+			return C.CString(string(jsonErr(err)))
+		}
+		return C.CString(string(data))
+	case <-timer:
+		return C.CString(`{"error":"timeout"}`)
+	}
+
+}
+
+//export Unlisten
+func Unlisten(subID C.ulonglong) *C.char {
+
+	id := uint64(subID)
+
+	v, ok := subTable.Load(id)
+	if !ok {
+		return C.CString(`{"ok":false, "error":"invalid sub id"}`)
+	}
+	sub := v.(*subWrap)
+
+	sub.cancel()
+	<-sub.done
+
+	if _, err := sub.conn.Exec(context.Background(), "UNLISTEN *"); err != nil {
+		sub.conn.Release()
+		subTable.Delete(id)
+		return C.CString(string(jsonErr(err)))
+	}
+
+	sub.conn.Release()
+	subTable.Delete(id)
+
+	return C.CString(`{"ok":true}`)
+
+}
@@ -11,10 +11,10 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
-	"time"
 	"unsafe"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -26,6 +26,34 @@ type poolWrap struct {
 	conn *pgxpool.Pool
 }
 
+// execer is the subset of pgx.Conn/pgxpool.Pool/pgx.Tx shared by every
+// execution path, so queryJSON/execJSON can run against a plain
+// connection, a pool, or a transaction without caring which.
+type execer interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+func resolveExecer(handle uint64) (execer, error) {
+
+	v, ok := connTable.Load(handle)
+	if !ok {
+		return nil, fmt.Errorf("invalid handle")
+	}
+
+	switch w := v.(type) {
+	case *poolWrap:
+		return w.conn, nil
+	case *connWrap:
+		return w.conn, nil
+	case *txWrap:
+		return w.tx, nil
+	default:
+		return nil, fmt.Errorf("invalid handle")
+	}
+
+}
+
 var (
 	hCounter  uint64
 	connTable sync.Map // id(uint64) -> *connWrap
@@ -95,35 +123,6 @@ func ConnectJSON(conninfo *C.char) *C.char {
 
 }
 
-//export ConnectPool
-func ConnectPool(conninfo *C.char) *C.char {
-
-	ci := C.GoString(conninfo)
-	ctx := context.Background()
-
-	cfg, err := pgxpool.ParseConfig(ci)
-	if err != nil {
-		return C.CString(string(jsonErr(err)))
-	}
-
-	cfg.MaxConns = 10
-	cfg.MinConns = 1
-	cfg.HealthCheckPeriod = 1 * time.Minute
-
-	pool, err := pgxpool.NewWithConfig(ctx, cfg)
-	if err != nil {
-		return C.CString(string(jsonErr(err)))
-	}
-
-	id := atomic.AddUint64(&hCounter, 1)
-	connTable.Store(id, &poolWrap{conn: pool})
-
-	resp := fmt.Sprintf(`{"handle":%d}`, id)
-
-	return C.CString(resp)
-
-}
-
 //export CloseJSON
 func CloseJSON(handle C.ulonglong) *C.char {
 
@@ -166,18 +165,16 @@ func ClosePool(handle C.ulonglong) *C.char {
 
 }
 
-func queryJSON(handle uint64, query string, format string) *C.char {
+func queryJSON(ctx context.Context, handle uint64, query string, format string) *C.char {
 
 	var data []byte
 
-	v, ok := connTable.Load(handle)
-	if !ok {
-		return C.CString(`{"error":"invalid handle"}`)
+	e, err := resolveExecer(handle)
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
 	}
 
-	ctx := context.Background()
-
-	rows, err := v.(*poolWrap).conn.Query(ctx, query)
+	rows, err := e.Query(ctx, query)
 	if err != nil {
 		return C.CString(string(jsonErr(err)))
 	}
@@ -199,16 +196,14 @@ func queryJSON(handle uint64, query string, format string) *C.char {
 
 }
 
-func execJSON(handle uint64, query string) *C.char {
+func execJSON(ctx context.Context, handle uint64, query string) *C.char {
 
-	v, ok := connTable.Load(handle)
-	if !ok {
-		return C.CString(`{"error":"invalid handle"}`)
+	e, err := resolveExecer(handle)
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
 	}
 
-	ctx := context.Background()
-
-	ct, err := v.(*poolWrap).conn.Exec(ctx, query)
+	ct, err := e.Exec(ctx, query)
 	if err != nil {
 		return C.CString(string(jsonErr(err)))
 	}
@@ -219,11 +214,11 @@ func execJSON(handle uint64, query string) *C.char {
 
 }
 
-func queryParamsJSON(handle uint64, query string, params string, format string) *C.char {
+func queryParamsJSON(ctx context.Context, handle uint64, query string, params string, format string) *C.char {
 
-	v, ok := connTable.Load(handle)
-	if !ok {
-		return C.CString(`{"error":"invalid handle"}`)
+	e, err := resolveExecer(handle)
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
 	}
 
 	args, err := jsonToArgs([]byte(params))
@@ -232,8 +227,7 @@ func queryParamsJSON(handle uint64, query string, params string, format string)
 	}
 
 	var data []byte
-	ctx := context.Background()
-	rows, err := v.(*poolWrap).conn.Query(ctx, query, args...)
+	rows, err := e.Query(ctx, query, args...)
 	if err != nil {
 		return C.CString(string(jsonErr(err)))
 	}
@@ -255,11 +249,11 @@ func queryParamsJSON(handle uint64, query string, params string, format string)
 
 }
 
-func execParamsJSON(handle uint64, query string, params string) *C.char {
+func execParamsJSON(ctx context.Context, handle uint64, query string, params string) *C.char {
 
-	v, ok := connTable.Load(handle)
-	if !ok {
-		return C.CString(`{"error":"invalid handle"}`)
+	e, err := resolveExecer(handle)
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
 	}
 
 	args, err := jsonToArgs([]byte(params))
@@ -267,9 +261,7 @@ func execParamsJSON(handle uint64, query string, params string) *C.char {
 		return C.CString(string(jsonErr(fmt.Errorf("bad params json: %w", err))))
 	}
 
-	ctx := context.Background()
-
-	ct, err := v.(*poolWrap).conn.Exec(ctx, query, args...)
+	ct, err := e.Exec(ctx, query, args...)
 	if err != nil {
 		return C.CString(string(jsonErr(err)))
 	}
@@ -288,10 +280,12 @@ func Execute(handle C.ulonglong, query *C.char, params *C.char, format *C.char)
 	q := C.GoString(query)
 	p := C.GoString(params)
 
+	ctx := context.Background()
+
 	if len(p) == 0 {
-		return execJSON(id, q)
+		return execJSON(ctx, id, q)
 	} else {
-		return execParamsJSON(id, q, p)
+		return execParamsJSON(ctx, id, q, p)
 	}
 
 }
@@ -305,10 +299,12 @@ func Query(handle C.ulonglong, query *C.char, params *C.char, format *C.char) *C
 	p := C.GoString(params)
 	f := C.GoString(format)
 
+	ctx := context.Background()
+
 	if len(p) == 0 {
-		return queryJSON(id, q, f)
+		return queryJSON(ctx, id, q, f)
 	} else {
-		return queryParamsJSON(id, q, p, f)
+		return queryParamsJSON(ctx, id, q, p, f)
 	}
 
 }
@@ -0,0 +1,246 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// poolConfig mirrors the pgxpool.Config / pgx.ConnConfig knobs that are
+// useful to tune from the host language. Everything else (host, user,
+// ...) is expected to already be present in Conninfo, since
+// pgxpool.ParseConfig understands the full libpq connection string
+// syntax. TLS is the one family of knobs broken out as structured
+// fields rather than left to the caller to embed in Conninfo by hand,
+// since callers built via config_json can't freely tack on extra
+// "key=value" pairs to a conninfo string.
+type poolConfig struct {
+	Conninfo               string     `json:"conninfo"`
+	MaxConns               *int32     `json:"max_conns"`
+	MinConns               *int32     `json:"min_conns"`
+	MaxConnLifetime        *string    `json:"max_conn_lifetime"`
+	MaxConnIdleTime        *string    `json:"max_conn_idle_time"`
+	HealthCheckPeriod      *string    `json:"health_check_period"`
+	DefaultQueryExecMode   *string    `json:"default_query_exec_mode"`
+	StatementCacheCapacity *int       `json:"statement_cache_capacity"`
+	TLS                    *tlsConfig `json:"tls"`
+}
+
+// tlsConfig mirrors the libpq sslmode/sslcert/sslkey/sslrootcert/
+// sslpassword keywords. Rather than hand-building a crypto/tls.Config,
+// these are folded into the conninfo string before it is handed to
+// pgxpool.ParseConfig, reusing pgconn's own well-tested TLS setup.
+type tlsConfig struct {
+	Mode     *string `json:"mode"`
+	CertFile *string `json:"cert_file"`
+	KeyFile  *string `json:"key_file"`
+	RootCert *string `json:"root_cert_file"`
+	Password *string `json:"password"`
+}
+
+func (t *tlsConfig) each(add func(keyword string, v string)) {
+	if t.Mode != nil {
+		add("sslmode", *t.Mode)
+	}
+	if t.CertFile != nil {
+		add("sslcert", *t.CertFile)
+	}
+	if t.KeyFile != nil {
+		add("sslkey", *t.KeyFile)
+	}
+	if t.RootCert != nil {
+		add("sslrootcert", *t.RootCert)
+	}
+	if t.Password != nil {
+		add("sslpassword", *t.Password)
+	}
+}
+
+func quoteDSNValue(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+func (t *tlsConfig) dsnParams() string {
+
+	var parts []string
+	t.each(func(keyword, v string) {
+		parts = append(parts, keyword+"="+quoteDSNValue(v))
+	})
+
+	return strings.Join(parts, " ")
+
+}
+
+// isURLConninfo reports whether conninfo is pgx's URL form
+// (postgres://...) rather than libpq keyword/value form, since the two
+// need different surgery to add extra parameters.
+func isURLConninfo(conninfo string) bool {
+	return strings.HasPrefix(conninfo, "postgres://") || strings.HasPrefix(conninfo, "postgresql://")
+}
+
+// applyTLS folds tlsConfig's fields into conninfo, whichever syntax it
+// is in: query parameters for the URL form, space-separated
+// keyword='value' pairs for the libpq DSN form.
+func applyTLS(conninfo string, t *tlsConfig) (string, error) {
+
+	if t == nil {
+		return conninfo, nil
+	}
+
+	if isURLConninfo(conninfo) {
+		u, err := url.Parse(conninfo)
+		if err != nil {
+			return "", fmt.Errorf("bad conninfo url: %w", err)
+		}
+		q := u.Query()
+		t.each(func(keyword, v string) {
+			q.Set(keyword, v)
+		})
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+
+	if extra := t.dsnParams(); extra != "" {
+		conninfo = strings.TrimSpace(conninfo + " " + extra)
+	}
+
+	return conninfo, nil
+
+}
+
+var queryExecModes = map[string]pgx.QueryExecMode{
+	"cache_statement": pgx.QueryExecModeCacheStatement,
+	"cache_describe":  pgx.QueryExecModeCacheDescribe,
+	"describe_exec":   pgx.QueryExecModeDescribeExec,
+	"exec":            pgx.QueryExecModeExec,
+	"simple_protocol": pgx.QueryExecModeSimpleProtocol,
+}
+
+func (c poolConfig) apply(cfg *pgxpool.Config) error {
+
+	if c.MaxConns != nil {
+		cfg.MaxConns = *c.MaxConns
+	}
+	if c.MinConns != nil {
+		cfg.MinConns = *c.MinConns
+	}
+	if c.MaxConnLifetime != nil {
+		d, err := time.ParseDuration(*c.MaxConnLifetime)
+		if err != nil {
+			return fmt.Errorf("bad max_conn_lifetime: %w", err)
+		}
+		cfg.MaxConnLifetime = d
+	}
+	if c.MaxConnIdleTime != nil {
+		d, err := time.ParseDuration(*c.MaxConnIdleTime)
+		if err != nil {
+			return fmt.Errorf("bad max_conn_idle_time: %w", err)
+		}
+		cfg.MaxConnIdleTime = d
+	}
+	if c.HealthCheckPeriod != nil {
+		d, err := time.ParseDuration(*c.HealthCheckPeriod)
+		if err != nil {
+			return fmt.Errorf("bad health_check_period: %w", err)
+		}
+		cfg.HealthCheckPeriod = d
+	}
+	if c.DefaultQueryExecMode != nil {
+		mode, ok := queryExecModes[*c.DefaultQueryExecMode]
+		if !ok {
+			return fmt.Errorf("unknown default_query_exec_mode %q", *c.DefaultQueryExecMode)
+		}
+		cfg.ConnConfig.DefaultQueryExecMode = mode
+	}
+	if c.StatementCacheCapacity != nil {
+		cfg.ConnConfig.StatementCacheCapacity = *c.StatementCacheCapacity
+	}
+
+	return nil
+
+}
+
+//export ConnectPoolJSON
+func ConnectPoolJSON(configJSON *C.char) *C.char {
+
+	dec := json.NewDecoder(bytes.NewReader([]byte(C.GoString(configJSON))))
+	dec.DisallowUnknownFields()
+
+	var pc poolConfig
+	if err := dec.Decode(&pc); err != nil {
+		return C.CString(string(jsonErr(fmt.Errorf("bad config json: %w", err))))
+	}
+
+	ci, err := applyTLS(pc.Conninfo, pc.TLS)
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	cfg, err := pgxpool.ParseConfig(ci)
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	if err := pc.apply(cfg); err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	id := atomic.AddUint64(&hCounter, 1)
+	connTable.Store(id, &poolWrap{conn: pool})
+
+	return C.CString(fmt.Sprintf(`{"handle":%d}`, id))
+
+}
+
+//export PoolStats
+func PoolStats(handle C.ulonglong) *C.char {
+
+	v, ok := connTable.Load(uint64(handle))
+	if !ok {
+		return C.CString(`{"error":"invalid handle"}`)
+	}
+	pw, ok := v.(*poolWrap)
+	if !ok {
+		return C.CString(`{"error":"handle is not a pool"}`)
+	}
+
+	s := pw.conn.Stat()
+
+	resp, err := json.Marshal(map[string]any{
+		"acquired_conns":         s.AcquiredConns(),
+		"idle_conns":             s.IdleConns(),
+		"total_conns":            s.TotalConns(),
+		"max_conns":              s.MaxConns(),
+		"new_conns_count":        s.NewConnsCount(),
+		"acquire_count":          s.AcquireCount(),
+		"acquire_duration_ms":    s.AcquireDuration().Milliseconds(),
+		"empty_acquire_count":    s.EmptyAcquireCount(),
+		"canceled_acquire_count": s.CanceledAcquireCount(),
+	})
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	return C.CString(string(resp))
+
+}
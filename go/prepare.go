@@ -0,0 +1,153 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// A named prepared statement lives on one physical connection, so it
+// must be pinned to a pgxpool.Conn checked out of the pool for as long
+// as the name is in use; it is returned to the pool on Deallocate.
+//
+// The choice between pgx's cache-statement and simple-protocol modes is
+// a per-pool default set via ConnectPoolJSON's default_query_exec_mode,
+// not per statement.
+type psWrap struct {
+	acquired *pgxpool.Conn
+	sql      string
+}
+
+type psKey struct {
+	handle uint64
+	name   string
+}
+
+var psTable sync.Map // psKey -> *psWrap
+
+//export Prepare
+func Prepare(handle C.ulonglong, name *C.char, sql *C.char) *C.char {
+
+	v, ok := connTable.Load(uint64(handle))
+	if !ok {
+		return C.CString(`{"error":"invalid handle"}`)
+	}
+	pw, ok := v.(*poolWrap)
+	if !ok {
+		return C.CString(`{"error":"handle is not a pool"}`)
+	}
+
+	n := C.GoString(name)
+	key := psKey{handle: uint64(handle), name: n}
+
+	ctx := context.Background()
+	acquired, err := pw.conn.Acquire(ctx)
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	if _, err := acquired.Conn().Prepare(ctx, n, C.GoString(sql)); err != nil {
+		acquired.Release()
+		return C.CString(string(jsonErr(err)))
+	}
+
+	if _, loaded := psTable.LoadOrStore(key, &psWrap{acquired: acquired, sql: C.GoString(sql)}); loaded {
+		acquired.Release()
+		return C.CString(string(jsonErr(fmt.Errorf("statement %q already prepared on this handle", n))))
+	}
+
+	return C.CString(`{"ok":true}`)
+
+}
+
+//export QueryPrepared
+func QueryPrepared(handle C.ulonglong, name *C.char, params *C.char, format *C.char) *C.char {
+
+	key := psKey{handle: uint64(handle), name: C.GoString(name)}
+	v, ok := psTable.Load(key)
+	if !ok {
+		return C.CString(`{"error":"statement not prepared"}`)
+	}
+	ps := v.(*psWrap)
+
+	args, err := jsonToArgs([]byte(C.GoString(params)))
+	if err != nil {
+		return C.CString(string(jsonErr(fmt.Errorf("bad params json: %w", err))))
+	}
+
+	ctx := context.Background()
+	rows, err := ps.acquired.Conn().Query(ctx, C.GoString(name), args...)
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+	defer rows.Close()
+
+	var data []byte
+	if strings.ToLower(C.GoString(format)) == "json" {
+		data, err = rowsToJSON(rows)
+	} else {
+		data, err = rowsToList(rows)
+	}
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	return C.CString(string(data))
+
+}
+
+//export ExecutePrepared
+func ExecutePrepared(handle C.ulonglong, name *C.char, params *C.char) *C.char {
+
+	key := psKey{handle: uint64(handle), name: C.GoString(name)}
+	v, ok := psTable.Load(key)
+	if !ok {
+		return C.CString(`{"error":"statement not prepared"}`)
+	}
+	ps := v.(*psWrap)
+
+	args, err := jsonToArgs([]byte(C.GoString(params)))
+	if err != nil {
+		return C.CString(string(jsonErr(fmt.Errorf("bad params json: %w", err))))
+	}
+
+	ctx := context.Background()
+	ct, err := ps.acquired.Conn().Exec(ctx, C.GoString(name), args...)
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	return C.CString(fmt.Sprintf(`{"rows_affected":%d}`, ct.RowsAffected()))
+
+}
+
+//export Deallocate
+func Deallocate(handle C.ulonglong, name *C.char) *C.char {
+
+	key := psKey{handle: uint64(handle), name: C.GoString(name)}
+	v, ok := psTable.Load(key)
+	if !ok {
+		return C.CString(`{"ok":false, "error":"statement not prepared"}`)
+	}
+	ps := v.(*psWrap)
+
+	ctx := context.Background()
+	err := ps.acquired.Conn().Deallocate(ctx, C.GoString(name))
+
+	ps.acquired.Release()
+	psTable.Delete(key)
+
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	return C.CString(`{"ok":true}`)
+
+}
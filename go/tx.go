@@ -0,0 +1,206 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type txWrap struct {
+	tx pgx.Tx
+
+	mu         sync.Mutex
+	savepoints map[string]bool
+}
+
+var savepointIdent = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+type txOptions struct {
+	IsoLevel       string `json:"isolation_level"`
+	AccessMode     string `json:"access_mode"`
+	DeferrableMode string `json:"deferrable_mode"`
+}
+
+func (o txOptions) toPgx() pgx.TxOptions {
+
+	opts := pgx.TxOptions{}
+
+	if o.IsoLevel != "" {
+		opts.IsoLevel = pgx.TxIsoLevel(strings.ToLower(o.IsoLevel))
+	}
+	if o.AccessMode != "" {
+		opts.AccessMode = pgx.TxAccessMode(strings.ToLower(o.AccessMode))
+	}
+	if o.DeferrableMode != "" {
+		opts.DeferrableMode = pgx.TxDeferrableMode(strings.ToLower(o.DeferrableMode))
+	}
+
+	return opts
+
+}
+
+//export BeginTx
+func BeginTx(handle C.ulonglong, optionsJSON *C.char) *C.char {
+
+	v, ok := connTable.Load(uint64(handle))
+	if !ok {
+		return C.CString(`{"error":"invalid handle"}`)
+	}
+	pw, ok := v.(*poolWrap)
+	if !ok {
+		return C.CString(`{"error":"handle is not a pool"}`)
+	}
+
+	var opts txOptions
+	if raw := C.GoString(optionsJSON); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &opts); err != nil {
+			return C.CString(string(jsonErr(fmt.Errorf("bad options json: %w", err))))
+		}
+	}
+
+	ctx := context.Background()
+	tx, err := pw.conn.BeginTx(ctx, opts.toPgx())
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	id := atomic.AddUint64(&hCounter, 1)
+	connTable.Store(id, &txWrap{tx: tx, savepoints: make(map[string]bool)})
+
+	return C.CString(fmt.Sprintf(`{"tx":%d}`, id))
+
+}
+
+func loadTx(handle C.ulonglong) (*txWrap, error) {
+
+	v, ok := connTable.Load(uint64(handle))
+	if !ok {
+		return nil, fmt.Errorf("invalid handle")
+	}
+	w, ok := v.(*txWrap)
+	if !ok {
+		return nil, fmt.Errorf("handle is not a transaction")
+	}
+
+	return w, nil
+
+}
+
+//export ExecuteTx
+func ExecuteTx(tx C.ulonglong, query *C.char, params *C.char) *C.char {
+
+	if _, err := loadTx(tx); err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	return Execute(tx, query, params, nil)
+
+}
+
+//export QueryTx
+func QueryTx(tx C.ulonglong, query *C.char, params *C.char, format *C.char) *C.char {
+
+	if _, err := loadTx(tx); err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	return Query(tx, query, params, format)
+
+}
+
+//export Commit
+func Commit(tx C.ulonglong) *C.char {
+
+	w, err := loadTx(tx)
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	err = w.tx.Commit(context.Background())
+	connTable.Delete(uint64(tx))
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	return C.CString(`{"ok":true}`)
+
+}
+
+//export Rollback
+func Rollback(tx C.ulonglong) *C.char {
+
+	w, err := loadTx(tx)
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	err = w.tx.Rollback(context.Background())
+	connTable.Delete(uint64(tx))
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	return C.CString(`{"ok":true}`)
+
+}
+
+//export Savepoint
+func Savepoint(tx C.ulonglong, name *C.char) *C.char {
+
+	w, err := loadTx(tx)
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	n := C.GoString(name)
+	if !savepointIdent.MatchString(n) {
+		return C.CString(string(jsonErr(fmt.Errorf("invalid savepoint name %q", n))))
+	}
+
+	if _, err := w.tx.Exec(context.Background(), "SAVEPOINT "+n); err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	w.mu.Lock()
+	w.savepoints[n] = true
+	w.mu.Unlock()
+
+	return C.CString(`{"ok":true}`)
+
+}
+
+//export RollbackTo
+func RollbackTo(tx C.ulonglong, name *C.char) *C.char {
+
+	w, err := loadTx(tx)
+	if err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	n := C.GoString(name)
+
+	w.mu.Lock()
+	known := w.savepoints[n]
+	w.mu.Unlock()
+
+	if !known {
+		return C.CString(string(jsonErr(fmt.Errorf("unknown savepoint %q", n))))
+	}
+
+	if _, err := w.tx.Exec(context.Background(), "ROLLBACK TO SAVEPOINT "+n); err != nil {
+		return C.CString(string(jsonErr(err)))
+	}
+
+	return C.CString(`{"ok":true}`)
+
+}